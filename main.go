@@ -30,22 +30,35 @@ func main() {
 	// Register endpoints for backend
 	router.Route("/api", func(router chi.Router) {
 		router.Use(render.SetContentType(render.ContentTypeJSON))
+		router.Use(rs.RejectInsecureAdminToken)
 		router.Route("/{branch}", func(router chi.Router) {
 			router.Use(server.BranchCtx)
 			router.Route("/candidates", func(router chi.Router) {
 				router.Get("/", rs.GetCandidates)
-				router.Post("/", rs.PostCandidates)
+				router.With(rs.RequireHTTPS).Post("/", rs.PostCandidates)
+				router.With(rs.RequireHTTPS).Post("/bulk", rs.PostCandidatesBulk)
+				router.Get("/unvoted", rs.GetUnvotedCandidates)
 				router.Route("/{id}", func(router chi.Router) {
 					router.Route("/votes", func(router chi.Router) {
+						router.Use(rs.RequireHTTPS)
 						router.Patch("/", rs.PatchVotes)
 					})
+					router.Post("/verify", rs.PostVerifyCandidate)
 				})
 			})
 			router.Route("/answers", func(router chi.Router) {
 				router.Get("/", rs.GetAnswers)
 			})
 			router.Get("/leaderboard", rs.GetLeaderboard)
+			router.Route("/turnout", func(router chi.Router) {
+				router.Get("/percentage", rs.GetTurnoutPercentage)
+			})
 			router.Get("/questions", rs.GetQuestions)
+			router.With(rs.RequireHTTPS).Post("/recount", rs.PostRecount)
+			router.Route("/archives", func(router chi.Router) {
+				router.With(rs.RequireHTTPS).Post("/", rs.PostArchive)
+				router.Get("/diff", rs.GetArchiveDiff)
+			})
 		})
 	})
 
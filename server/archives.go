@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// archivesCollection returns the collection holding the labelled snapshots of a branch's results.
+func (rs *AppResource) archivesCollection(branch string) *mongo.Collection {
+	return rs.Db().Collection(branch + "_archives")
+}
+
+// findArchive gets the archive with the label, or mongo.ErrNoDocuments if there isn't one.
+func (rs *AppResource) findArchive(r *http.Request, branch string, label string) (*Archive, error) {
+	archive := Archive{}
+	err := rs.archivesCollection(branch).FindOne(r.Context(), bson.M{"label": label}).Decode(&archive)
+	if err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// diffArchives compares the candidates in two archives.
+// Candidates in both are grouped by whether their votes changed, and the rest by whether they were added or removed.
+func diffArchives(a *Archive, b *Archive) ArchiveDiff {
+	diff := ArchiveDiff{
+		A:         a.Label,
+		B:         b.Label,
+		Changed:   []ArchiveDiffEntry{},
+		Unchanged: []ArchiveDiffEntry{},
+		Added:     []LeaderboardEntry{},
+		Removed:   []LeaderboardEntry{},
+	}
+
+	inB := make(map[primitive.ObjectID]LeaderboardEntry, len(b.Candidates))
+	for _, candidate := range b.Candidates {
+		inB[candidate.Id] = candidate
+	}
+
+	inA := make(map[primitive.ObjectID]bool, len(a.Candidates))
+	for _, candidate := range a.Candidates {
+		inA[candidate.Id] = true
+
+		after, ok := inB[candidate.Id]
+		if !ok {
+			diff.Removed = append(diff.Removed, candidate)
+			continue
+		}
+
+		entry := ArchiveDiffEntry{
+			Id:     candidate.Id,
+			Name:   after.Name,
+			VotesA: candidate.Votes,
+			VotesB: after.Votes,
+			Delta:  after.Votes - candidate.Votes,
+		}
+		if entry.Delta == 0 {
+			diff.Unchanged = append(diff.Unchanged, entry)
+		} else {
+			diff.Changed = append(diff.Changed, entry)
+		}
+	}
+
+	for _, candidate := range b.Candidates {
+		if !inA[candidate.Id] {
+			diff.Added = append(diff.Added, candidate)
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestPostArchiveDuplicateLabel(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("label already used", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{AdminToken: "secret"}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, testCandidate),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+		)
+
+		r := newTestRequest(http.MethodPost, "/api/senate/archives")
+		r.Body = httpBody(`{"label": "day-1"}`)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-Admin-Token", "secret")
+		w := httptest.NewRecorder()
+		rs.PostArchive(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusConflict)
+		}
+		if label := decodeResponse(t, w)["data"].(map[string]any)["label"]; label == nil {
+			t.Error("the failure should explain what's wrong with the label")
+		}
+	})
+}
+
+func TestDiffArchives(t *testing.T) {
+	documents := testCandidates(4)
+	kept, changed, removed, added := documents[0], documents[1], documents[2], documents[3]
+	entry := func(document bson.D, votes int32) LeaderboardEntry {
+		entry := LeaderboardEntry{}
+		raw, _ := bson.Marshal(document)
+		_ = bson.Unmarshal(raw, &entry)
+		entry.Votes = votes
+		return entry
+	}
+
+	a := &Archive{Label: "a", Candidates: []LeaderboardEntry{entry(kept, 1), entry(changed, 2), entry(removed, 3)}}
+	b := &Archive{Label: "b", Candidates: []LeaderboardEntry{entry(kept, 1), entry(changed, 5), entry(added, 4)}}
+	diff := diffArchives(a, b)
+
+	if len(diff.Unchanged) != 1 || diff.Unchanged[0].Delta != 0 {
+		t.Errorf("got unchanged %v", diff.Unchanged)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].VotesA != 2 || diff.Changed[0].VotesB != 5 || diff.Changed[0].Delta != 3 {
+		t.Errorf("got changed %v", diff.Changed)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Votes != 3 {
+		t.Errorf("got removed %v", diff.Removed)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Votes != 4 {
+		t.Errorf("got added %v", diff.Added)
+	}
+}
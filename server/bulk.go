@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrBatchTooLarge = errors.New("batch too large")
+
+// decodeBulkCandidates reads a bulk request's candidates one at a time,
+// stopping with ErrBatchTooLarge as soon as there are more than max of them
+// so that an oversized batch is never decoded in full.
+func decodeBulkCandidates(body io.Reader, max int) (*BulkCandidateRequest, error) {
+	decoder := json.NewDecoder(body)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	request := &BulkCandidateRequest{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key != "candidates" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token == nil {
+			request.Candidates = nil
+			continue
+		}
+		if token != json.Delim('[') {
+			return nil, fmt.Errorf("candidates must be an array, not %v", token)
+		}
+
+		request.Candidates = []CandidateRequest{}
+		for decoder.More() {
+			if len(request.Candidates) == max {
+				return nil, ErrBatchTooLarge
+			}
+
+			candidate := CandidateRequest{}
+			if err := decoder.Decode(&candidate); err != nil {
+				return nil, err
+			}
+			request.Candidates = append(request.Candidates, candidate)
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+
+	if request.Candidates == nil {
+		return nil, ErrMissingCandidates
+	}
+	return request, nil
+}
+
+// expectDelim reads the next token and checks that it's the delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, not %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const testBulkCandidate = `{"name": "John Smith", "answers": ["a", "b", "c", "d"]}`
+
+// newBulkRequest makes a bulk import request for the senate branch with the given candidates.
+func newBulkRequest(candidates ...string) *http.Request {
+	r := newTestRequest(http.MethodPost, "/api/senate/candidates/bulk")
+	r.Body = httpBody(`{"candidates": [` + strings.Join(candidates, ", ") + `]}`)
+	return r
+}
+
+func TestDecodeBulkCandidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr error
+	}{
+		{name: "within the limit", body: `{"candidates": [` + testBulkCandidate + `]}`, want: 1},
+		{name: "exactly the limit", body: `{"candidates": [` + testBulkCandidate + `, ` + testBulkCandidate + `]}`, want: 2},
+		{name: "empty batch", body: `{"candidates": []}`, want: 0},
+		{name: "other keys are skipped", body: `{"source": {"a": [1]}, "candidates": [` + testBulkCandidate + `]}`, want: 1},
+		{name: "missing candidates", body: `{}`, wantErr: ErrMissingCandidates},
+		{name: "null candidates", body: `{"candidates": null}`, wantErr: ErrMissingCandidates},
+		{
+			name:    "over the limit",
+			body:    `{"candidates": [` + strings.Repeat(testBulkCandidate+`, `, 3) + testBulkCandidate + `]}`,
+			wantErr: ErrBatchTooLarge,
+		},
+		{
+			// The rest of the body is never read, so it doesn't matter that it's invalid.
+			name:    "stops reading once over the limit",
+			body:    `{"candidates": [` + strings.Repeat(testBulkCandidate+`, `, 3) + `not json`,
+			wantErr: ErrBatchTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := decodeBulkCandidates(strings.NewReader(test.body), 2)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("got error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if len(request.Candidates) != test.want {
+				t.Errorf("got %d candidates, want %d", len(request.Candidates), test.want)
+			}
+		})
+	}
+}
+
+func TestPostCandidatesBulkLimits(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("batch over the limit", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{MaxBatchSize: 2, MaxBodyBytes: 1 << 20}}
+
+		w := httptest.NewRecorder()
+		rs.PostCandidatesBulk(w, newBulkRequest(testBulkCandidate, testBulkCandidate, testBulkCandidate))
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+		}
+		response := decodeResponse(t, w)
+		if response["status"] != string(Fail) {
+			t.Errorf("got JSend status %v, want %q", response["status"], Fail)
+		}
+		if max := response["data"].(map[string]any)["maxBatchSize"]; max != float64(2) {
+			t.Errorf("got maxBatchSize %v, want 2", max)
+		}
+		if started := mt.GetAllStartedEvents(); len(started) != 0 {
+			t.Errorf("got %d commands, want nothing sent to the database", len(started))
+		}
+	})
+
+	mt.Run("body over the limit", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{MaxBatchSize: 100, MaxBodyBytes: 64}}
+
+		w := httptest.NewRecorder()
+		rs.PostCandidatesBulk(w, newBulkRequest(testBulkCandidate, testBulkCandidate))
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+		}
+		if max := decodeResponse(t, w)["data"].(map[string]any)["maxBodyBytes"]; max != float64(64) {
+			t.Errorf("got maxBodyBytes %v, want 64", max)
+		}
+		if started := mt.GetAllStartedEvents(); len(started) != 0 {
+			t.Errorf("got %d commands, want nothing sent to the database", len(started))
+		}
+	})
+}
+
+func TestPostCandidatesBulkMixedResults(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("valid candidates are inserted and invalid ones reported", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{MaxBatchSize: 10, MaxBodyBytes: 1 << 20}}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		w := httptest.NewRecorder()
+		rs.PostCandidatesBulk(w, newBulkRequest(
+			testBulkCandidate,
+			`{"answers": ["a", "b", "c", "d"]}`,
+			`{"name": "Jane Doe", "answers": ["a", "b", "c"]}`,
+			testBulkCandidate,
+		))
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusCreated)
+		}
+		data := decodeResponse(t, w)["data"].(map[string]any)
+		if data["inserted"] != float64(2) || data["failed"] != float64(2) {
+			t.Errorf("got %v inserted and %v failed, want 2 and 2", data["inserted"], data["failed"])
+		}
+
+		wantErrors := []ValidationErrors{
+			nil,
+			{"name": ErrMissingName.Error()},
+			{"answers": ErrWrongAnswerCount.Error()},
+			nil,
+		}
+		results := data["results"].([]any)
+		for i, want := range wantErrors {
+			result := results[i].(map[string]any)
+			if result["index"] != float64(i) {
+				t.Errorf("result %d: got index %v", i, result["index"])
+			}
+			if want == nil {
+				if result["status"] != string(BulkImportOk) || result["_id"] == nil {
+					t.Errorf("result %d: got %v, want ok with an id", i, result)
+				}
+				continue
+			}
+
+			if result["status"] != string(BulkImportFailed) {
+				t.Errorf("result %d: got status %v, want failed", i, result["status"])
+			}
+			errs := result["errors"].(map[string]any)
+			for field, message := range want {
+				if errs[field] != message {
+					t.Errorf("result %d: %s: got %v, want %q", i, field, errs[field], message)
+				}
+			}
+		}
+
+		documents, err := mt.GetStartedEvent().Command.Lookup("documents").Array().Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(documents) != 2 {
+			t.Errorf("got %d documents inserted, want 2", len(documents))
+		}
+	})
+
+	mt.Run("nothing is inserted when every candidate is invalid", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{MaxBatchSize: 10, MaxBodyBytes: 1 << 20}}
+
+		w := httptest.NewRecorder()
+		rs.PostCandidatesBulk(w, newBulkRequest(`{"name": "Jane Doe", "answers": ["a"]}`))
+
+		if status := decodeResponse(t, w)["status"]; status != string(Fail) {
+			t.Errorf("got JSend status %v, want %q", status, Fail)
+		}
+		if started := mt.GetAllStartedEvents(); len(started) != 0 {
+			t.Errorf("got %d commands, want nothing sent to the database", len(started))
+		}
+	})
+}
+
+func TestBulkExampleMatchesValidation(t *testing.T) {
+	request, err := os.Open("examples/senate/candidates/bulk/post_request.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer request.Close()
+	data, err := decodeBulkCandidates(request, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile("examples/senate/candidates/bulk/post_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Data struct {
+			Results []BulkImportResult `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	results := response.Data.Results
+	if len(results) != len(data.Candidates) {
+		t.Fatalf("example response has %d results for %d candidates", len(results), len(data.Candidates))
+	}
+	for i, candidate := range data.Candidates {
+		errs := candidate.Validate()
+		if (len(errs) == 0) != (results[i].Status == BulkImportOk) {
+			t.Errorf("candidate %d: example says %q, but validation gives %v", i, results[i].Status, errs)
+		}
+		for field, message := range results[i].Errors {
+			if errs[field] != message {
+				t.Errorf("candidate %d: example says %s is %q, but validation gives %q", i, field, message, errs[field])
+			}
+		}
+	}
+}
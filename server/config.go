@@ -0,0 +1,140 @@
+package server
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings that are read from the environment.
+type Config struct {
+	// AdminToken is the secret admins send in the X-Admin-Token header.
+	// Leaving it empty disables admin access.
+	AdminToken string
+	// HideResultsUntilVoted hides vote counts from a voter until they have voted in that branch.
+	HideResultsUntilVoted bool
+	// MaxBatchSize is the most candidates that can be imported in one bulk request.
+	MaxBatchSize int
+	// MaxBodyBytes is the largest bulk request body that will be read.
+	MaxBodyBytes int64
+	// EligibleVoters is how many voters are eligible to vote in each branch.
+	// Branches that are missing have no known eligible count.
+	EligibleVoters map[string]int
+	// OrderTTL is how long a voter keeps seeing the same candidate order.
+	// Zero means every request gets a new order.
+	OrderTTL time.Duration
+	// RequireHTTPS rejects requests to sensitive endpoints that didn't arrive over HTTPS.
+	RequireHTTPS bool
+	// TrustedProxies are the networks whose X-Forwarded-Proto header is believed.
+	TrustedProxies []*net.IPNet
+	// FingerprintForwardedFor fingerprints voters by the X-Forwarded-For address that trusted proxies report,
+	// instead of the proxy's own address. Changing it changes every voter's fingerprint,
+	// so it should be set before voting starts.
+	FingerprintForwardedFor bool
+	// VoteLog records every vote in an append-only log that recounts rebuild the vote counts from.
+	// Votes are logged in transactions, so the database must be a replica set, which Atlas clusters are.
+	VoteLog bool
+	// PercentagePrecision is how many decimal places percentages are rounded to, from 0 to 4.
+	PercentagePrecision int
+	// LargestRemainder adjusts rounded percentages that are shares of a whole, so they add up to exactly 100.
+	LargestRemainder bool
+}
+
+// LoadConfig reads the Config from environmental variables.
+func LoadConfig() Config {
+	return Config{
+		AdminToken:            os.Getenv("ADMIN_TOKEN"),
+		HideResultsUntilVoted: envBool("HIDE_RESULTS_UNTIL_VOTED"),
+		MaxBatchSize:          envPositiveInt("MAX_BATCH_SIZE", 100),
+		MaxBodyBytes:          int64(envPositiveInt("MAX_BODY_BYTES", 1<<20)),
+		EligibleVoters: map[string]int{
+			"senate":   envInt("SENATE_ELIGIBLE_VOTERS", 0),
+			"treasury": envInt("TREASURY_ELIGIBLE_VOTERS", 0),
+		},
+		OrderTTL:                envDuration("ORDER_TTL", 0),
+		RequireHTTPS:            envBool("REQUIRE_HTTPS"),
+		TrustedProxies:          envNetworks("TRUSTED_PROXIES"),
+		FingerprintForwardedFor: envBool("FINGERPRINT_FORWARDED_FOR"),
+		VoteLog:                 envBool("VOTE_LOG"),
+		PercentagePrecision:     envClampedInt("PERCENTAGE_PRECISION", 1, 0, 4),
+		LargestRemainder:        envBool("PERCENTAGE_LARGEST_REMAINDER"),
+	}
+}
+
+// envBool reports whether the environmental variable is set to a true value.
+func envBool(key string) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && value
+}
+
+// envInt reads an integer from the environmental variable, or returns the fallback if it isn't set.
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// envPositiveInt is like envInt, but also falls back if the value isn't positive.
+func envPositiveInt(key string, fallback int) int {
+	value := envInt(key, fallback)
+	if value <= 0 {
+		log.Printf("'%s' must be positive; using %d instead", key, fallback)
+		return fallback
+	}
+	return value
+}
+
+// envClampedInt is like envInt, but limits the value to the range between min and max.
+func envClampedInt(key string, fallback int, min int, max int) int {
+	value := envInt(key, fallback)
+	clamped := value
+	if clamped < min {
+		clamped = min
+	}
+	if clamped > max {
+		clamped = max
+	}
+	if clamped != value {
+		log.Printf("'%s' must be between %d and %d; using %d instead", key, min, max, clamped)
+	}
+	return clamped
+}
+
+// envDuration reads a duration such as "72h" from the environmental variable, or returns the fallback if it isn't set.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// envNetworks reads a comma separated list of IP addresses and CIDR ranges from the environmental variable.
+func envNetworks(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Fatalf("Invalid entry %q in '%s': %v", entry, key, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
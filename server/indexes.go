@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// branches are the branches that BranchCtx accepts.
+var branches = []string{"senate", "treasury"}
+
+// EnsureIndexes creates the indexes that every branch's collections rely on.
+// Creating an index that already exists does nothing, so this is safe to run on every start.
+func (rs *AppResource) EnsureIndexes(ctx context.Context) error {
+	for _, branch := range branches {
+		// Let MongoDB delete stored orders as soon as they expire.
+		_, err := rs.ordersCollection(branch).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+
+		// A voter can only log one vote per candidate with the same idempotency key.
+		_, err = rs.voteLogCollection(branch).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "fingerprint", Value: 1},
+				{Key: "candidate", Value: 1},
+				{Key: "idempotencyKey", Value: 1},
+			},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"idempotencyKey": bson.M{"$exists": true}}),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Each archive label can only be used once per branch.
+		_, err = rs.archivesCollection(branch).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "label", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
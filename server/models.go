@@ -2,8 +2,12 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
@@ -21,23 +25,89 @@ const (
 type Candidate struct {
 	Id      primitive.ObjectID `json:"_id" bson:"_id"`
 	Name    string             `json:"name"`
-	Votes   int32              `json:"votes"`
+	Votes   *int32             `json:"votes,omitempty"`
 	Answers []string           `json:"answers"`
 }
 
 type LeaderboardEntry struct {
-	Id    primitive.ObjectID `json:"_id" bson:"_id"`
-	Name  string             `json:"name"`
-	Votes int32              `json:"votes"`
+	Id         primitive.ObjectID `json:"_id" bson:"_id"`
+	Name       string             `json:"name"`
+	Votes      int32              `json:"votes"`
+	Percentage *float64           `json:"percentage,omitempty" bson:"-"`
 }
 
 type Answer struct {
 	Id     primitive.ObjectID `json:"_id" bson:"_id"`
 	Name   string             `json:"name"`
-	Votes  int32              `json:"votes"`
+	Votes  *int32             `json:"votes,omitempty"`
 	Answer string             `json:"answer"`
 }
 
+type TurnoutPercentage struct {
+	Voters              int64    `json:"voters"`
+	EligibleVoters      int      `json:"eligibleVoters,omitempty"`
+	Percentage          *float64 `json:"percentage"`
+	PercentageAvailable bool     `json:"percentageAvailable"`
+}
+
+type BulkImportStatus string
+
+const (
+	BulkImportOk     BulkImportStatus = "ok"
+	BulkImportFailed BulkImportStatus = "failed"
+)
+
+type BulkImportResult struct {
+	Index  int                 `json:"index"`
+	Status BulkImportStatus    `json:"status"`
+	Id     *primitive.ObjectID `json:"_id,omitempty"`
+	Errors ValidationErrors    `json:"errors,omitempty"`
+}
+
+type FieldMatch struct {
+	Match    bool    `json:"match"`
+	Expected *string `json:"expected"`
+	Stored   *string `json:"stored"`
+}
+
+type CandidateVerification struct {
+	Match        bool         `json:"match"`
+	Name         FieldMatch   `json:"name"`
+	Answers      []FieldMatch `json:"answers"`
+	Hash         string       `json:"hash"`
+	ExpectedHash string       `json:"expectedHash"`
+}
+
+type RecountEntry struct {
+	Id     primitive.ObjectID `json:"_id" bson:"_id"`
+	Name   string             `json:"name"`
+	Before int32              `json:"before" bson:"votes"`
+	After  int32              `json:"after" bson:"-"`
+}
+
+type Archive struct {
+	Label      string             `json:"label"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	Candidates []LeaderboardEntry `json:"candidates"`
+}
+
+type ArchiveDiffEntry struct {
+	Id     primitive.ObjectID `json:"_id"`
+	Name   string             `json:"name"`
+	VotesA int32              `json:"votesA"`
+	VotesB int32              `json:"votesB"`
+	Delta  int32              `json:"delta"`
+}
+
+type ArchiveDiff struct {
+	A         string             `json:"a"`
+	B         string             `json:"b"`
+	Changed   []ArchiveDiffEntry `json:"changed"`
+	Unchanged []ArchiveDiffEntry `json:"unchanged"`
+	Added     []LeaderboardEntry `json:"added"`
+	Removed   []LeaderboardEntry `json:"removed"`
+}
+
 type Response struct {
 	Status JSendStatus `json:"status"`
 	Data   any         `json:"data"`
@@ -76,19 +146,70 @@ type CandidateRequest struct {
 var ErrMissingName = errors.New("missing name")
 var ErrMissingAnswers = errors.New("missing answers")
 var ErrAnswersTooLong = errors.New("one or more answers are too long")
+var ErrWrongAnswerCount = fmt.Errorf("there must be exactly %d answers", questionCount)
 
-func (request *CandidateRequest) Bind(r *http.Request) error {
+// ValidationErrors maps the name of each invalid field to what's wrong with it.
+type ValidationErrors map[string]string
+
+func (errs ValidationErrors) Error() string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = fmt.Sprintf("%s: %s", field, errs[field])
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks every field of the request, so that all the problems can be reported at once.
+func (request *CandidateRequest) Validate() ValidationErrors {
+	errs := ValidationErrors{}
 	if request.Name == "" {
-		return ErrMissingName
+		errs["name"] = ErrMissingName.Error()
 	}
 	if request.Answers == nil {
-		return ErrMissingAnswers
+		errs["answers"] = ErrMissingAnswers.Error()
+	} else if len(request.Answers) != questionCount {
+		errs["answers"] = ErrWrongAnswerCount.Error()
 	}
 	for _, answer := range request.Answers {
 		if len(answer) > AnswerMaxLength {
-			return ErrAnswersTooLong
+			errs["answers"] = ErrAnswersTooLong.Error()
+			break
 		}
 	}
 
+	return errs
+}
+
+func (request *CandidateRequest) Bind(r *http.Request) error {
+	if errs := request.Validate(); len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+type BulkCandidateRequest struct {
+	Candidates []CandidateRequest `json:"candidates"`
+}
+
+var ErrMissingCandidates = errors.New("missing candidates")
+
+type ArchiveRequest struct {
+	Label string `json:"label"`
+}
+
+var ErrMissingLabel = errors.New("missing label")
+
+func (request *ArchiveRequest) Bind(r *http.Request) error {
+	if request.Label == "" {
+		return ErrMissingLabel
+	}
+
 	return nil
 }
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCandidateRequestValidate(t *testing.T) {
+	answers := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		name    string
+		request CandidateRequest
+		want    ValidationErrors
+	}{
+		{name: "valid", request: CandidateRequest{Name: "John Smith", Answers: answers}, want: ValidationErrors{}},
+		{name: "empty answers are allowed", request: CandidateRequest{Name: "John Smith", Answers: []string{"", "", "", ""}}, want: ValidationErrors{}},
+		{
+			name:    "missing name",
+			request: CandidateRequest{Answers: answers},
+			want:    ValidationErrors{"name": ErrMissingName.Error()},
+		},
+		{
+			name:    "missing answers",
+			request: CandidateRequest{Name: "John Smith"},
+			want:    ValidationErrors{"answers": ErrMissingAnswers.Error()},
+		},
+		{
+			name:    "too few answers",
+			request: CandidateRequest{Name: "John Smith", Answers: answers[:3]},
+			want:    ValidationErrors{"answers": ErrWrongAnswerCount.Error()},
+		},
+		{
+			name:    "too many answers",
+			request: CandidateRequest{Name: "John Smith", Answers: append(answers, "e")},
+			want:    ValidationErrors{"answers": ErrWrongAnswerCount.Error()},
+		},
+		{
+			name:    "answer too long",
+			request: CandidateRequest{Name: "John Smith", Answers: []string{strings.Repeat("a", AnswerMaxLength+1), "b", "c", "d"}},
+			want:    ValidationErrors{"answers": ErrAnswersTooLong.Error()},
+		},
+		{
+			name:    "every field is reported",
+			request: CandidateRequest{},
+			want:    ValidationErrors{"name": ErrMissingName.Error(), "answers": ErrMissingAnswers.Error()},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.request.Validate()
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for field, message := range test.want {
+				if got[field] != message {
+					t.Errorf("%s: got %q, want %q", field, got[field], message)
+				}
+			}
+		})
+	}
+}
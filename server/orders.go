@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type voterOrder struct {
+	Order     []primitive.ObjectID `bson:"order"`
+	ExpiresAt time.Time            `bson:"expiresAt"`
+}
+
+// ordersCollection returns the collection storing each voter's candidate order in a branch.
+func (rs *AppResource) ordersCollection(branch string) *mongo.Collection {
+	return rs.Db().Collection(branch + "_orders")
+}
+
+// applyOrder sorts the candidates by their position in order.
+// Candidates that aren't in order are shuffled and put at the end.
+func applyOrder(candidates []Candidate, order []primitive.ObjectID) []Candidate {
+	byId := make(map[primitive.ObjectID]Candidate, len(candidates))
+	for _, candidate := range candidates {
+		byId[candidate.Id] = candidate
+	}
+
+	ordered := make([]Candidate, 0, len(candidates))
+	for _, id := range order {
+		if candidate, ok := byId[id]; ok {
+			ordered = append(ordered, candidate)
+			delete(byId, id)
+		}
+	}
+
+	var unordered []Candidate
+	for _, candidate := range candidates {
+		if _, ok := byId[candidate.Id]; ok {
+			unordered = append(unordered, candidate)
+		}
+	}
+	randomize(unordered)
+	return append(ordered, unordered...)
+}
+
+// orderKey identifies whose order a request is for.
+// Clients that send an X-Voter-Id header, such as the voter's student ID, get the same order on every device.
+// Otherwise the voter's fingerprint is used, which only stays the same for one browser on one network.
+func (rs *AppResource) orderKey(r *http.Request) string {
+	if voterId := r.Header.Get("X-Voter-Id"); voterId != "" {
+		hash := sha256.Sum256([]byte(voterId))
+		return "id:" + hex.EncodeToString(hash[:])
+	}
+	return rs.voterFingerprint(r)
+}
+
+// orderForVoter puts the candidates in the requesting voter's stored order, so they see the same order every time,
+// on any device that sends the same X-Voter-Id.
+// A new random order is stored if the voter doesn't have one yet or theirs has expired;
+// expired orders are also deleted by the TTL index from EnsureIndexes.
+// New candidates are added to the end of an existing order without changing when it expires.
+func (rs *AppResource) orderForVoter(r *http.Request, branch string, candidates []Candidate) ([]Candidate, error) {
+	collection := rs.ordersCollection(branch)
+	key := rs.orderKey(r)
+	now := time.Now()
+
+	stored := voterOrder{}
+	err := collection.FindOne(
+		r.Context(),
+		bson.M{"_id": key, "expiresAt": bson.M{"$gt": now}},
+	).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		stored = voterOrder{ExpiresAt: now.Add(rs.Config.OrderTTL)}
+	} else if err != nil {
+		return nil, err
+	}
+
+	ordered := applyOrder(candidates, stored.Order)
+	order := make([]primitive.ObjectID, len(ordered))
+	for i, candidate := range ordered {
+		order[i] = candidate.Id
+	}
+	if equalOrders(order, stored.Order) {
+		return ordered, nil
+	}
+
+	_, err = collection.UpdateOne(
+		r.Context(),
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"order": order, "expiresAt": stored.ExpiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	return ordered, err
+}
+
+// equalOrders checks if two orders list the same ids in the same positions.
+func equalOrders(a []primitive.ObjectID, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
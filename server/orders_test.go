@@ -0,0 +1,175 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestApplyOrder(t *testing.T) {
+	var candidates []Candidate
+	for i := 0; i < 4; i++ {
+		candidates = append(candidates, Candidate{Id: primitive.NewObjectID()})
+	}
+	a, b, c, d := candidates[0].Id, candidates[1].Id, candidates[2].Id, candidates[3].Id
+
+	t.Run("stored order is kept", func(t *testing.T) {
+		order := []primitive.ObjectID{c, a, d, b}
+		got := applyOrder(candidates, order)
+		for i, candidate := range got {
+			if candidate.Id != order[i] {
+				t.Fatalf("position %d: got %v, want %v", i, candidate.Id, order[i])
+			}
+		}
+	})
+
+	t.Run("new candidates go at the end and removed ones are dropped", func(t *testing.T) {
+		order := []primitive.ObjectID{b, primitive.NewObjectID(), a}
+		got := applyOrder(candidates, order)
+		if len(got) != len(candidates) {
+			t.Fatalf("got %d candidates, want %d", len(got), len(candidates))
+		}
+		if got[0].Id != b || got[1].Id != a {
+			t.Errorf("got %v and %v first, want the stored order", got[0].Id, got[1].Id)
+		}
+		rest := map[primitive.ObjectID]bool{got[2].Id: true, got[3].Id: true}
+		if !rest[c] || !rest[d] {
+			t.Errorf("new candidates should come last, got %v", got[2:])
+		}
+	})
+}
+
+// orderResponse is the mocked reply to looking up a stored order.
+func orderResponse(order []primitive.ObjectID, expiresAt time.Time) bson.D {
+	ids := bson.A{}
+	for _, id := range order {
+		ids = append(ids, id)
+	}
+	return mtest.CreateCursorResponse(0, "voting.senate_orders", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: "key"},
+		{Key: "order", Value: ids},
+		{Key: "expiresAt", Value: expiresAt},
+	})
+}
+
+func TestOrderForVoter(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	config := Config{OrderTTL: time.Hour}
+	var candidates []Candidate
+	for i := 0; i < 3; i++ {
+		candidates = append(candidates, Candidate{Id: primitive.NewObjectID()})
+	}
+	a, b, c := candidates[0].Id, candidates[1].Id, candidates[2].Id
+
+	mt.Run("stored order is reused", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		stored := []primitive.ObjectID{c, a, b}
+		mt.AddMockResponses(orderResponse(stored, time.Now().Add(time.Minute)))
+
+		got, err := rs.orderForVoter(newTestRequest(http.MethodGet, "/"), "senate", candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, candidate := range got {
+			if candidate.Id != stored[i] {
+				t.Fatalf("position %d: got %v, want the stored order", i, candidate.Id)
+			}
+		}
+		if names := commandNames(mt); !equalStrings(names, []string{"find"}) {
+			t.Errorf("got commands %v, want the order only read", names)
+		}
+	})
+
+	mt.Run("expired order is replaced", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		// Expired orders don't match the lookup, so the database returns nothing.
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "voting.senate_orders", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		before := time.Now()
+		got, err := rs.orderForVoter(newTestRequest(http.MethodGet, "/"), "senate", candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(candidates) {
+			t.Fatalf("got %d candidates, want %d", len(got), len(candidates))
+		}
+
+		started := mt.GetAllStartedEvents()
+		if names := commandNames(mt); !equalStrings(names, []string{"find", "update"}) {
+			t.Fatalf("got commands %v, want a new order stored", names)
+		}
+		if _, ok := started[0].Command.Lookup("filter", "expiresAt", "$gt").TimeOK(); !ok {
+			t.Error("the lookup should skip expired orders")
+		}
+		update := started[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+		if !update.Lookup("upsert").Boolean() {
+			t.Error("a new order should be upserted")
+		}
+		expiresAt := update.Lookup("u", "$set", "expiresAt").Time()
+		if expiresAt.Before(before.Add(time.Hour).Truncate(time.Millisecond)) {
+			t.Errorf("got expiresAt %v, want an hour from now", expiresAt)
+		}
+	})
+
+	mt.Run("new candidate is appended without extending the expiry", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		expiresAt := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+		mt.AddMockResponses(
+			orderResponse([]primitive.ObjectID{b, a}, expiresAt),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		got, err := rs.orderForVoter(newTestRequest(http.MethodGet, "/"), "senate", candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[0].Id != b || got[1].Id != a || got[2].Id != c {
+			t.Errorf("got %v, want the stored order with the new candidate last", got)
+		}
+
+		update := mt.GetAllStartedEvents()[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+		if stored := update.Lookup("u", "$set", "expiresAt").Time(); !stored.Equal(expiresAt) {
+			t.Errorf("got expiresAt %v, want it kept at %v", stored, expiresAt)
+		}
+		if order, _ := update.Lookup("u", "$set", "order").Array().Values(); len(order) != 3 {
+			t.Errorf("got %d ids stored, want 3", len(order))
+		}
+	})
+
+	mt.Run("same voter id on another device", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		mt.AddMockResponses(
+			orderResponse([]primitive.ObjectID{a, b, c}, time.Now().Add(time.Minute)),
+			orderResponse([]primitive.ObjectID{a, b, c}, time.Now().Add(time.Minute)),
+		)
+
+		for _, device := range []struct{ remoteAddr, userAgent string }{
+			{"192.0.2.1:1234", "laptop"},
+			{"198.51.100.7:5678", "phone"},
+		} {
+			r := newTestRequest(http.MethodGet, "/")
+			r.RemoteAddr = device.remoteAddr
+			r.Header.Set("User-Agent", device.userAgent)
+			r.Header.Set("X-Voter-Id", "student-42")
+			if _, err := rs.orderForVoter(r, "senate", candidates); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var keys []string
+		for _, started := range mt.GetAllStartedEvents() {
+			keys = append(keys, started.Command.Lookup("filter", "_id").StringValue())
+		}
+		if len(keys) != 2 || keys[0] != keys[1] {
+			t.Errorf("got order keys %v, want the same order for both devices", keys)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"math"
+	"sort"
+)
+
+// roundPercentage rounds a percentage to the given number of decimal places.
+func roundPercentage(value float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(value*scale) / scale
+}
+
+// percentage computes part as a rounded percentage of whole, capped at 100.
+// It returns 0 if whole is 0.
+func percentage(part int64, whole int64, precision int) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return roundPercentage(math.Min(float64(part)/float64(whole)*100, 100), precision)
+}
+
+// percentages computes each value as a rounded percentage of their total.
+// With largestRemainder, the percentages are rounded down and the leftover units handed to the largest remainders,
+// so that they always add up to exactly 100.
+func percentages(values []int64, precision int, largestRemainder bool) []float64 {
+	var total int64
+	for _, value := range values {
+		total += value
+	}
+
+	result := make([]float64, len(values))
+	if total <= 0 {
+		return result
+	}
+	if !largestRemainder {
+		for i, value := range values {
+			result[i] = percentage(value, total, precision)
+		}
+		return result
+	}
+
+	// Work in whole units of the smallest displayed step, such as tenths of a percent, to avoid float error.
+	scale := int64(math.Pow(10, float64(precision)))
+	units := make([]int64, len(values))
+	remainders := make([]int64, len(values))
+	leftover := 100 * scale
+	for i, value := range values {
+		units[i] = value * 100 * scale / total
+		remainders[i] = value * 100 * scale % total
+		leftover -= units[i]
+	}
+
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := int64(0); i < leftover; i++ {
+		units[order[i]]++
+	}
+
+	for i := range units {
+		result[i] = float64(units[i]) / float64(scale)
+	}
+	return result
+}
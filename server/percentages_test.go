@@ -0,0 +1,123 @@
+package server
+
+import (
+	"math"
+	"testing"
+)
+
+// sum adds up the percentages, rounding away float error at the given precision.
+func sum(values []float64, precision int) float64 {
+	var total float64
+	for _, value := range values {
+		total += value
+	}
+	return roundPercentage(total, precision)
+}
+
+func TestRoundPercentage(t *testing.T) {
+	tests := []struct {
+		value     float64
+		precision int
+		want      float64
+	}{
+		{value: 33.3333, precision: 0, want: 33},
+		{value: 33.3333, precision: 1, want: 33.3},
+		{value: 33.3333, precision: 2, want: 33.33},
+		{value: 66.6666, precision: 0, want: 67},
+		{value: 66.6666, precision: 2, want: 66.67},
+		{value: 12.5, precision: 0, want: 13},
+		{value: 100, precision: 2, want: 100},
+		{value: 0, precision: 1, want: 0},
+	}
+	for _, test := range tests {
+		if got := roundPercentage(test.value, test.precision); got != test.want {
+			t.Errorf("roundPercentage(%v, %d) = %v, want %v", test.value, test.precision, got, test.want)
+		}
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	tests := []struct {
+		name        string
+		part, whole int64
+		precision   int
+		want        float64
+	}{
+		{name: "simple", part: 11, whole: 40, precision: 1, want: 27.5},
+		{name: "rounded", part: 1, whole: 3, precision: 2, want: 33.33},
+		{name: "all", part: 40, whole: 40, precision: 1, want: 100},
+		{name: "clamped to 100", part: 50, whole: 40, precision: 1, want: 100},
+		{name: "zero whole", part: 5, whole: 0, precision: 1, want: 0},
+		{name: "negative whole", part: 5, whole: -1, precision: 1, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := percentage(test.part, test.whole, test.precision); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPercentages(t *testing.T) {
+	tests := []struct {
+		name             string
+		values           []int64
+		precision        int
+		largestRemainder bool
+		want             []float64
+		wantSum          float64
+	}{
+		{name: "all zero", values: []int64{0, 0, 0}, precision: 1, want: []float64{0, 0, 0}, wantSum: 0},
+		{name: "all zero with largest remainder", values: []int64{0, 0, 0}, precision: 1, largestRemainder: true, want: []float64{0, 0, 0}, wantSum: 0},
+		{name: "no candidates", values: []int64{}, precision: 1, largestRemainder: true, want: []float64{}, wantSum: 0},
+		{name: "single candidate", values: []int64{7}, precision: 2, want: []float64{100}, wantSum: 100},
+		{name: "single candidate with largest remainder", values: []int64{7}, precision: 2, largestRemainder: true, want: []float64{100}, wantSum: 100},
+		{name: "thirds at 0 places", values: []int64{1, 1, 1}, precision: 0, want: []float64{33, 33, 33}, wantSum: 99},
+		{name: "thirds at 1 place", values: []int64{1, 1, 1}, precision: 1, want: []float64{33.3, 33.3, 33.3}, wantSum: 99.9},
+		{name: "thirds at 2 places", values: []int64{1, 1, 1}, precision: 2, want: []float64{33.33, 33.33, 33.33}, wantSum: 99.99},
+		{
+			// Ties go to the earlier candidate, which is the one with more votes on the leaderboard.
+			name: "thirds at 0 places with largest remainder", values: []int64{1, 1, 1}, precision: 0, largestRemainder: true,
+			want: []float64{34, 33, 33}, wantSum: 100,
+		},
+		{
+			name: "thirds at 1 place with largest remainder", values: []int64{1, 1, 1}, precision: 1, largestRemainder: true,
+			want: []float64{33.4, 33.3, 33.3}, wantSum: 100,
+		},
+		{
+			name: "thirds at 2 places with largest remainder", values: []int64{1, 1, 1}, precision: 2, largestRemainder: true,
+			want: []float64{33.34, 33.33, 33.33}, wantSum: 100,
+		},
+		{
+			// Plain rounding gives 67 + 17 + 17 = 101.
+			name: "rounding up past 100", values: []int64{4, 1, 1}, precision: 0,
+			want: []float64{67, 17, 17}, wantSum: 101,
+		},
+		{
+			name: "largest remainder brings it back to 100", values: []int64{4, 1, 1}, precision: 0, largestRemainder: true,
+			want: []float64{67, 17, 16}, wantSum: 100,
+		},
+		{
+			name: "largest remainder goes to the biggest fraction", values: []int64{1, 2}, precision: 0, largestRemainder: true,
+			want: []float64{33, 67}, wantSum: 100,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := percentages(test.values, test.precision, test.largestRemainder)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if math.Abs(got[i]-test.want[i]) > 1e-9 {
+					t.Errorf("got %v, want %v", got, test.want)
+					break
+				}
+			}
+			if total := sum(got, test.precision); total != test.wantSum {
+				t.Errorf("got a sum of %v, want %v", total, test.wantSum)
+			}
+		})
+	}
+}
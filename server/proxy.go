@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// isTrustedProxy checks if the address belongs to one of the configured proxies.
+func (rs *AppResource) isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range rs.Config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the IP address of whoever opened the connection.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns the IP address the voter's request came from.
+// When FingerprintForwardedFor is set and the request came through trusted proxies,
+// the closest address in X-Forwarded-For that isn't a proxy is used instead of the proxy's own address.
+func (rs *AppResource) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !rs.Config.FingerprintForwardedFor || !rs.isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		ip = address
+		if !rs.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// isSecure checks if the request was made over HTTPS, either directly or through a trusted proxy.
+func (rs *AppResource) isSecure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return rs.isTrustedProxy(remoteIP(r)) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// RequireHTTPS rejects requests that weren't made over HTTPS when RequireHTTPS is set.
+func (rs *AppResource) RequireHTTPS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rs.Config.RequireHTTPS && !rs.isSecure(r) {
+			render.Status(r, http.StatusForbidden)
+			render.Render(w, r, NewResponseFail(map[string]string{
+				"message": "This endpoint must be accessed over HTTPS",
+			}))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectInsecureAdminToken rejects requests that send an admin token without HTTPS when RequireHTTPS is set,
+// so admins find out that their token was sent in the clear instead of it being silently ignored.
+func (rs *AppResource) RejectInsecureAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rs.Config.RequireHTTPS && r.Header.Get("X-Admin-Token") != "" && !rs.isSecure(r) {
+			render.Status(r, http.StatusForbidden)
+			render.Render(w, r, NewResponseFail(map[string]string{
+				"message": "Admin tokens must only be sent over HTTPS",
+			}))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	config := Config{RequireHTTPS: true, TrustedProxies: []*net.IPNet{proxies}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		tls        bool
+		config     Config
+		want       int
+	}{
+		{name: "off for local development", remoteAddr: "192.0.2.1:1234", config: Config{}, want: http.StatusOK},
+		{name: "direct HTTPS", remoteAddr: "192.0.2.1:1234", tls: true, config: config, want: http.StatusOK},
+		{name: "direct HTTP", remoteAddr: "192.0.2.1:1234", config: config, want: http.StatusForbidden},
+		{name: "HTTPS through a trusted proxy", remoteAddr: "10.1.2.3:1234", proto: "https", config: config, want: http.StatusOK},
+		{name: "HTTP through a trusted proxy", remoteAddr: "10.1.2.3:1234", proto: "http", config: config, want: http.StatusForbidden},
+		{name: "header from an untrusted client", remoteAddr: "192.0.2.1:1234", proto: "https", config: config, want: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := &AppResource{Config: test.config}
+			r := httptest.NewRequest(http.MethodPatch, "/api/senate/candidates/1/votes", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			w := httptest.NewRecorder()
+			rs.RequireHTTPS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+			if w.Code != test.want {
+				t.Errorf("got status %d, want %d", w.Code, test.want)
+			}
+		})
+	}
+}
+
+func TestRejectInsecureAdminToken(t *testing.T) {
+	rs := &AppResource{Config: Config{RequireHTTPS: true, AdminToken: "secret"}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name  string
+		token string
+		tls   bool
+		want  int
+	}{
+		{name: "no token over HTTP", want: http.StatusOK},
+		{name: "token over HTTP", token: "secret", want: http.StatusForbidden},
+		{name: "wrong token over HTTP", token: "guess", want: http.StatusForbidden},
+		{name: "token over HTTPS", token: "secret", tls: true, want: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/senate/leaderboard", nil)
+			if test.token != "" {
+				r.Header.Set("X-Admin-Token", test.token)
+			}
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			w := httptest.NewRecorder()
+			rs.RejectInsecureAdminToken(next).ServeHTTP(w, r)
+			if w.Code != test.want {
+				t.Errorf("got status %d, want %d", w.Code, test.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		optIn      bool
+		want       string
+	}{
+		{name: "remote address by default", remoteAddr: "10.1.2.3:1234", forwarded: "192.0.2.1", want: "10.1.2.3"},
+		{name: "forwarded address when opted in", remoteAddr: "10.1.2.3:1234", forwarded: "192.0.2.1", optIn: true, want: "192.0.2.1"},
+		{name: "closest untrusted address", remoteAddr: "10.1.2.3:1234", forwarded: "198.51.100.7, 192.0.2.1, 10.4.5.6", optIn: true, want: "192.0.2.1"},
+		{name: "header from an untrusted client", remoteAddr: "192.0.2.1:1234", forwarded: "198.51.100.7", optIn: true, want: "192.0.2.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := &AppResource{Config: Config{TrustedProxies: []*net.IPNet{proxies}, FingerprintForwardedFor: test.optIn}}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+
+			if got := rs.clientIP(r); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ const (
 
 type AppResource struct {
 	Client *mongo.Client
+	Config Config
 }
 
 // NewAppResource creates an AppResource that's connected to the database.
@@ -44,6 +45,10 @@ func NewAppResource() *AppResource {
 	}
 	rs := AppResource{
 		Client: client,
+		Config: LoadConfig(),
+	}
+	if err := rs.EnsureIndexes(context.TODO()); err != nil {
+		panic(err)
 	}
 	return &rs
 }
@@ -79,6 +84,14 @@ func BranchCtx(next http.Handler) http.Handler {
 	})
 }
 
+// renderResultsHidden tells the voter that they have to vote before they can see the results.
+func renderResultsHidden(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusForbidden)
+	render.Render(w, r, NewResponseFail(map[string]string{
+		"message": "Results are hidden until you have voted",
+	}))
+}
+
 // randomize randomizes a slice.
 func randomize[T any](x []T) {
 	rand.Seed(time.Now().UnixNano())
@@ -89,10 +102,19 @@ func randomize[T any](x []T) {
 
 // GetCandidates renders all the candidates.
 // The order of the candidates is randomized.
+// If OrderTTL is set, each voter keeps the same random order until it expires,
+// across devices if the client sends the voter's X-Voter-Id.
+// Votes are left out if the voter isn't allowed to see the results yet.
 func (rs *AppResource) GetCandidates(w http.ResponseWriter, r *http.Request) {
 	branch := r.Context().Value("branch").(string)
 	collection := rs.Db().Collection(branch)
 
+	showVotes, err := rs.canSeeResults(r, branch)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not check if the voter has voted"))
+		return
+	}
+
 	cur, err := collection.Find(r.Context(), bson.D{})
 	if err != nil {
 		render.Render(w, r, NewErrorResponse("Could not get cursor from db"))
@@ -101,6 +123,59 @@ func (rs *AppResource) GetCandidates(w http.ResponseWriter, r *http.Request) {
 	defer cur.Close(r.Context())
 
 	var candidates []Candidate
+	for cur.Next(r.Context()) {
+		candidate := Candidate{}
+		err := cur.Decode(&candidate)
+		if err != nil {
+			render.Render(w, r, NewErrorResponse("Could not decode into candidate"))
+			return
+		}
+		if !showVotes {
+			candidate.Votes = nil
+		}
+
+		candidates = append(candidates, candidate)
+	}
+	if rs.Config.OrderTTL > 0 {
+		candidates, err = rs.orderForVoter(r, branch, candidates)
+		if err != nil {
+			render.Render(w, r, NewErrorResponse("Could not get the voter's candidate order"))
+			return
+		}
+	} else {
+		randomize(candidates)
+	}
+	data := map[string]any{
+		"candidates": candidates,
+	}
+	render.Render(w, r, NewResponseSuccess(data))
+}
+
+// GetUnvotedCandidates renders the candidates that haven't received any votes, sorted by name.
+// If HideResultsUntilVoted is set, only admins and voters who have voted can see it.
+func (rs *AppResource) GetUnvotedCandidates(w http.ResponseWriter, r *http.Request) {
+	branch := r.Context().Value("branch").(string)
+	collection := rs.Db().Collection(branch)
+
+	showVotes, err := rs.canSeeResults(r, branch)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not check if the voter has voted"))
+		return
+	}
+	if !showVotes {
+		renderResultsHidden(w, r)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.M{"name": 1})
+	cur, err := collection.Find(r.Context(), bson.M{"votes": 0}, opts)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not get cursor from db"))
+		return
+	}
+	defer cur.Close(r.Context())
+
+	candidates := []Candidate{}
 	for cur.Next(r.Context()) {
 		candidate := Candidate{}
 		err := cur.Decode(&candidate)
@@ -111,7 +186,6 @@ func (rs *AppResource) GetCandidates(w http.ResponseWriter, r *http.Request) {
 
 		candidates = append(candidates, candidate)
 	}
-	randomize(candidates)
 	data := map[string]any{
 		"candidates": candidates,
 	}
@@ -122,12 +196,13 @@ func (rs *AppResource) GetCandidates(w http.ResponseWriter, r *http.Request) {
 func (rs *AppResource) PostCandidates(w http.ResponseWriter, r *http.Request) {
 	data := &CandidateRequest{}
 	err := render.Bind(r, data)
-	if errors.Is(err, ErrMissingName) {
-		render.Render(w, r, NewResponseFail(map[string]string{"name": err.Error()}))
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		render.Render(w, r, NewResponseFail(validationErrors))
 		return
 	}
-	if errors.Is(err, ErrMissingAnswers) || errors.Is(err, ErrAnswersTooLong) {
-		render.Render(w, r, NewResponseFail(map[string]string{"answers": err.Error()}))
+	if err != nil {
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "Could not decode the request body"}))
 		return
 	}
 
@@ -150,6 +225,83 @@ func (rs *AppResource) PostCandidates(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, NewResponseSuccess(nil))
 }
 
+// PostCandidatesBulk takes in a list of candidate JSONs and inserts the valid ones into the database.
+// The response has a result for each candidate, so the client can tell which ones failed and why.
+// Nothing is inserted if the body or the batch is too big.
+func (rs *AppResource) PostCandidatesBulk(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, rs.Config.MaxBodyBytes)
+	data, err := decodeBulkCandidates(r.Body, rs.Config.MaxBatchSize)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.Render(w, r, NewResponseFail(map[string]any{
+			"message":      fmt.Sprintf("request body is larger than the maximum of %d bytes", maxBytesError.Limit),
+			"maxBodyBytes": maxBytesError.Limit,
+		}))
+		return
+	}
+	if errors.Is(err, ErrBatchTooLarge) {
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.Render(w, r, NewResponseFail(map[string]any{
+			"candidates":   fmt.Sprintf("batch has more than the maximum of %d candidates", rs.Config.MaxBatchSize),
+			"maxBatchSize": rs.Config.MaxBatchSize,
+		}))
+		return
+	}
+	if errors.Is(err, ErrMissingCandidates) {
+		render.Render(w, r, NewResponseFail(map[string]string{"candidates": err.Error()}))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "Could not decode the request body"}))
+		return
+	}
+
+	results := make([]BulkImportResult, len(data.Candidates))
+	documents := make([]any, 0, len(data.Candidates))
+	for i, candidate := range data.Candidates {
+		results[i].Index = i
+		if errs := candidate.Validate(); len(errs) > 0 {
+			results[i].Status = BulkImportFailed
+			results[i].Errors = errs
+			continue
+		}
+
+		id := primitive.NewObjectID()
+		results[i].Status = BulkImportOk
+		results[i].Id = &id
+		documents = append(documents, bson.M{
+			"_id":     id,
+			"name":    candidate.Name,
+			"answers": candidate.Answers,
+			"votes":   0,
+		})
+	}
+
+	responseData := map[string]any{
+		"inserted": len(documents),
+		"failed":   len(data.Candidates) - len(documents),
+		"results":  results,
+	}
+	if len(documents) == 0 && len(data.Candidates) > 0 {
+		render.Render(w, r, NewResponseFail(responseData))
+		return
+	}
+
+	if len(documents) > 0 {
+		branch := r.Context().Value("branch").(string)
+		collection := rs.Db().Collection(branch)
+		_, err = collection.InsertMany(r.Context(), documents)
+		if err != nil {
+			render.Render(w, r, NewErrorResponse("There was an error adding the candidates to the database."))
+			return
+		}
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewResponseSuccess(responseData))
+}
+
 // PatchVotes increments a candidate's votes by one.
 func (rs *AppResource) PatchVotes(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
@@ -161,45 +313,106 @@ func (rs *AppResource) PatchVotes(w http.ResponseWriter, r *http.Request) {
 	branch := r.Context().Value("branch").(string)
 	collection := rs.Db().Collection(branch)
 
-	_, err = collection.UpdateOne(
-		r.Context(),
-		bson.M{
-			"_id": id,
-		},
-		bson.D{
-			{"$inc", bson.D{{"votes", 1}}},
-		},
-	)
-	if err != nil {
-		render.Render(w, r, NewErrorResponse("Could not increment votes"))
-		return
+	// With the vote log on, the vote is logged and counted in one transaction.
+	// Retries that send the same Idempotency-Key header are only counted once.
+	var matched bool
+	if rs.Config.VoteLog {
+		// A retry of a vote that was already counted still records the voter below,
+		// in case recording them is what failed the first time.
+		matched, err = rs.logVote(r, branch, id, r.Header.Get("Idempotency-Key"))
+		if err != nil && !errors.Is(err, ErrVoteAlreadyLogged) {
+			render.Render(w, r, NewErrorResponse("Could not log the vote"))
+			return
+		}
+	} else {
+		result, err := collection.UpdateOne(
+			r.Context(),
+			bson.M{
+				"_id": id,
+			},
+			bson.D{
+				{"$inc", bson.D{{"votes", 1}}},
+			},
+		)
+		if err != nil {
+			render.Render(w, r, NewErrorResponse("Could not increment votes"))
+			return
+		}
+		matched = result.MatchedCount > 0
+	}
+	if matched {
+		if err := rs.recordVoter(r, branch); err != nil {
+			render.Render(w, r, NewErrorResponse("Could not record the voter"))
+			return
+		}
 	}
 	render.Render(w, r, NewResponseSuccess(nil))
 }
 
-// GetAnswers renders everyone's answers grouped by question.
-// The order within each question is randomized.
-func (rs *AppResource) GetAnswers(w http.ResponseWriter, r *http.Request) {
+// PostVerifyCandidate compares the name and answers in the request with the stored candidate.
+// It renders which fields match, along with a hash of the stored content.
+func (rs *AppResource) PostVerifyCandidate(w http.ResponseWriter, r *http.Request) {
+	// An id that isn't an ObjectID can't belong to any candidate, so it's treated the same as a missing one.
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewResponseFail(map[string]string{
+			"_id": fmt.Sprintf(`No candidate with id "%s"`, chi.URLParam(r, "id")),
+		}))
+		return
+	}
+
+	data := &CandidateRequest{}
+	err = render.Bind(r, data)
+	var validationErrors ValidationErrors
+	if errors.As(err, &validationErrors) {
+		render.Render(w, r, NewResponseFail(validationErrors))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "Could not decode the request body"}))
+		return
+	}
+
 	branch := r.Context().Value("branch").(string)
 	collection := rs.Db().Collection(branch)
 
-	cur, err := collection.Find(r.Context(), bson.D{})
+	opts := options.FindOne().SetProjection(bson.M{"name": 1, "answers": 1})
+	candidate := Candidate{}
+	err = collection.FindOne(r.Context(), bson.M{"_id": id}, opts).Decode(&candidate)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewResponseFail(map[string]string{
+			"_id": fmt.Sprintf(`No candidate with id "%s"`, id.Hex()),
+		}))
+		return
+	}
 	if err != nil {
-		render.Render(w, r, NewErrorResponse("Could not get cursor from db"))
+		render.Render(w, r, NewErrorResponse("Could not get the candidate from db"))
 		return
 	}
-	defer cur.Close(r.Context())
 
+	responseData := map[string]any{
+		"verification": verifyCandidate(data, &candidate),
+	}
+	render.Render(w, r, NewResponseSuccess(responseData))
+}
+
+// groupAnswers groups the candidates' answers by question, keeping the candidates' order.
+// A question has no answers, rather than an empty list of them, if there are no candidates.
+func groupAnswers(candidates []Candidate) [questionCount][]Answer {
 	var answers [questionCount][]Answer
-	for cur.Next(r.Context()) {
-		candidate := Candidate{}
-		err := cur.Decode(&candidate)
-		if err != nil {
-			render.Render(w, r, NewErrorResponse("Could not decode into candidate"))
-			return
-		}
+	if len(candidates) == 0 {
+		return answers
+	}
 
-		for i := 0; i < questionCount; i++ {
+	for i := 0; i < questionCount; i++ {
+		answers[i] = make([]Answer, 0, len(candidates))
+	}
+	for _, candidate := range candidates {
+		// Candidates stored before the answer count was checked may have too few answers,
+		// so only the questions they answered are included.
+		for i := 0; i < questionCount && i < len(candidate.Answers); i++ {
 			answer := Answer{
 				Id:     candidate.Id,
 				Name:   candidate.Name,
@@ -209,6 +422,47 @@ func (rs *AppResource) GetAnswers(w http.ResponseWriter, r *http.Request) {
 			answers[i] = append(answers[i], answer)
 		}
 	}
+	return answers
+}
+
+// GetAnswers renders everyone's answers grouped by question.
+// The order within each question is randomized.
+// Votes are left out if the voter isn't allowed to see the results yet.
+func (rs *AppResource) GetAnswers(w http.ResponseWriter, r *http.Request) {
+	branch := r.Context().Value("branch").(string)
+	collection := rs.Db().Collection(branch)
+
+	showVotes, err := rs.canSeeResults(r, branch)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not check if the voter has voted"))
+		return
+	}
+
+	// Only fetch the fields that end up in the answers, and decode them all at once.
+	projection := bson.M{"_id": 1, "name": 1, "answers": 1}
+	if showVotes {
+		projection["votes"] = 1
+	}
+	opts := options.Find().SetProjection(projection)
+	cur, err := collection.Find(r.Context(), bson.D{}, opts)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not get cursor from db"))
+		return
+	}
+	defer cur.Close(r.Context())
+
+	var candidates []Candidate
+	if err := cur.All(r.Context(), &candidates); err != nil {
+		render.Render(w, r, NewErrorResponse("Could not decode into candidate"))
+		return
+	}
+
+	if !showVotes {
+		for i := range candidates {
+			candidates[i].Votes = nil
+		}
+	}
+	answers := groupAnswers(candidates)
 	for i := 0; i < questionCount; i++ {
 		randomize(answers[i])
 	}
@@ -219,11 +473,23 @@ func (rs *AppResource) GetAnswers(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, NewResponseSuccess(data))
 }
 
-// GetLeaderboard renders the list of candidates sorted from most to least votes.
+// GetLeaderboard renders the list of candidates sorted from most to least votes,
+// along with each candidate's percentage of the votes.
+// If HideResultsUntilVoted is set, only admins and voters who have voted can see it.
 func (rs *AppResource) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 	branch := r.Context().Value("branch").(string)
 	collection := rs.Db().Collection(branch)
 
+	showVotes, err := rs.canSeeResults(r, branch)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not check if the voter has voted"))
+		return
+	}
+	if !showVotes {
+		renderResultsHidden(w, r)
+		return
+	}
+
 	opts := options.Find().SetSort(bson.D{{"votes", -1}})
 	cur, err := collection.Find(r.Context(), bson.D{}, opts)
 	if err != nil {
@@ -243,12 +509,185 @@ func (rs *AppResource) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 
 		leaderboardEntries = append(leaderboardEntries, leaderboardEntry)
 	}
+
+	votes := make([]int64, len(leaderboardEntries))
+	for i, leaderboardEntry := range leaderboardEntries {
+		votes[i] = int64(leaderboardEntry.Votes)
+	}
+	shares := percentages(votes, rs.Config.PercentagePrecision, rs.Config.LargestRemainder)
+	for i := range leaderboardEntries {
+		leaderboardEntries[i].Percentage = &shares[i]
+	}
 	data := map[string]any{
 		"leaderboard": leaderboardEntries,
 	}
 	render.Render(w, r, NewResponseSuccess(data))
 }
 
+// GetTurnoutPercentage renders how many distinct voters have voted as a percentage of the eligible voters.
+// If the branch has no eligible count, only the number of voters is rendered.
+func (rs *AppResource) GetTurnoutPercentage(w http.ResponseWriter, r *http.Request) {
+	branch := r.Context().Value("branch").(string)
+
+	voters, err := rs.votersCollection(branch).CountDocuments(r.Context(), bson.M{})
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not count voters"))
+		return
+	}
+
+	turnout := TurnoutPercentage{Voters: voters}
+	if eligible := rs.Config.EligibleVoters[branch]; eligible > 0 {
+		turnoutPercentage := percentage(voters, int64(eligible), rs.Config.PercentagePrecision)
+		turnout.EligibleVoters = eligible
+		turnout.Percentage = &turnoutPercentage
+		turnout.PercentageAvailable = true
+	}
+
+	data := map[string]any{
+		"turnout": turnout,
+	}
+	render.Render(w, r, NewResponseSuccess(data))
+}
+
+// PostRecount rebuilds every candidate's votes from the vote log, and renders the counts before and after.
+// Only admins can recount, and only while the vote log is on.
+// If any candidate has more votes than the log, such as votes cast before the log was turned on,
+// nothing is changed and those candidates are rendered instead,
+// unless the "force" query parameter is "true", in which case the log counts are applied anyway.
+func (rs *AppResource) PostRecount(w http.ResponseWriter, r *http.Request) {
+	if !rs.isAdmin(r) {
+		render.Status(r, http.StatusForbidden)
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "Only admins can recount votes"}))
+		return
+	}
+	if !rs.Config.VoteLog {
+		render.Status(r, http.StatusConflict)
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "The vote log is off, so there is nothing to recount from"}))
+		return
+	}
+
+	branch := r.Context().Value("branch").(string)
+	force := r.URL.Query().Get("force") == "true"
+	entries, uncovered, err := rs.recount(r.Context(), branch, force)
+	if errors.Is(err, ErrVotesNotCovered) {
+		render.Status(r, http.StatusConflict)
+		render.Render(w, r, NewResponseFail(map[string]any{
+			"message":   "Some candidates have votes that aren't in the vote log, so a recount would remove them; recount with force=true to use the log anyway",
+			"uncovered": uncovered,
+		}))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not recount the votes"))
+		return
+	}
+
+	data := map[string]any{
+		"recount":   entries,
+		"uncovered": uncovered,
+	}
+	render.Render(w, r, NewResponseSuccess(data))
+}
+
+// PostArchive saves a snapshot of every candidate's votes under the label in the request.
+// Only admins can create archives, and each label can only be used once per branch.
+func (rs *AppResource) PostArchive(w http.ResponseWriter, r *http.Request) {
+	if !rs.isAdmin(r) {
+		render.Status(r, http.StatusForbidden)
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "Only admins can create archives"}))
+		return
+	}
+
+	data := &ArchiveRequest{}
+	err := render.Bind(r, data)
+	if errors.Is(err, ErrMissingLabel) {
+		render.Render(w, r, NewResponseFail(map[string]string{"label": err.Error()}))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, NewResponseFail(map[string]string{"message": "Could not decode the request body"}))
+		return
+	}
+
+	branch := r.Context().Value("branch").(string)
+	collection := rs.Db().Collection(branch)
+
+	opts := options.Find().SetSort(bson.D{{Key: "votes", Value: -1}}).SetProjection(bson.M{"name": 1, "votes": 1})
+	cur, err := collection.Find(r.Context(), bson.D{}, opts)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not get cursor from db"))
+		return
+	}
+	defer cur.Close(r.Context())
+
+	archive := Archive{Label: data.Label, CreatedAt: time.Now(), Candidates: []LeaderboardEntry{}}
+	if err := cur.All(r.Context(), &archive.Candidates); err != nil {
+		render.Render(w, r, NewErrorResponse("Could not decode into leaderboard entry"))
+		return
+	}
+
+	// Labels are kept unique by an index, so two archives can't be created with the same label at once.
+	_, err = rs.archivesCollection(branch).InsertOne(r.Context(), archive)
+	if mongo.IsDuplicateKeyError(err) {
+		render.Status(r, http.StatusConflict)
+		render.Render(w, r, NewResponseFail(map[string]string{
+			"label": fmt.Sprintf(`An archive labelled "%s" already exists`, data.Label),
+		}))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("There was an error adding the archive to the database."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, NewResponseSuccess(map[string]any{"archive": archive}))
+}
+
+// GetArchiveDiff renders how the votes changed between the archives labelled by the "a" and "b" query parameters.
+// If HideResultsUntilVoted is set, only admins and voters who have voted can see it.
+func (rs *AppResource) GetArchiveDiff(w http.ResponseWriter, r *http.Request) {
+	branch := r.Context().Value("branch").(string)
+
+	showVotes, err := rs.canSeeResults(r, branch)
+	if err != nil {
+		render.Render(w, r, NewErrorResponse("Could not check if the voter has voted"))
+		return
+	}
+	if !showVotes {
+		renderResultsHidden(w, r)
+		return
+	}
+
+	archives := make(map[string]*Archive, 2)
+	for _, param := range []string{"a", "b"} {
+		label := r.URL.Query().Get(param)
+		if label == "" {
+			render.Render(w, r, NewResponseFail(map[string]string{param: ErrMissingLabel.Error()}))
+			return
+		}
+
+		archive, err := rs.findArchive(r, branch, label)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			render.Status(r, http.StatusNotFound)
+			render.Render(w, r, NewResponseFail(map[string]string{
+				param: fmt.Sprintf(`No archive labelled "%s"`, label),
+			}))
+			return
+		}
+		if err != nil {
+			render.Render(w, r, NewErrorResponse("Could not get the archive from db"))
+			return
+		}
+		archives[param] = archive
+	}
+
+	data := map[string]any{
+		"diff": diffArchives(archives["a"], archives["b"]),
+	}
+	render.Render(w, r, NewResponseSuccess(data))
+}
+
 // GetQuestions renders the list of questions.
 func (rs *AppResource) GetQuestions(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, NewErrorResponse("Not implemented"))
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// testCandidates makes n candidate documents, as they are stored in the database.
+func testCandidates(n int) []bson.D {
+	documents := make([]bson.D, n)
+	for i := range documents {
+		documents[i] = bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "name", Value: fmt.Sprintf("Candidate %d", i)},
+			{Key: "answers", Value: bson.A{
+				fmt.Sprintf("%d-0", i), fmt.Sprintf("%d-1", i), fmt.Sprintf("%d-2", i), fmt.Sprintf("%d-3", i),
+			}},
+			{Key: "votes", Value: int32(i)},
+		}
+	}
+	return documents
+}
+
+// oldAnswers groups the documents' answers the way GetAnswers did before it decoded them in one batch.
+func oldAnswers(t testing.TB, documents []bson.D) [questionCount][]Answer {
+	values := make([]any, len(documents))
+	for i, document := range documents {
+		values[i] = document
+	}
+	cur, err := mongo.NewCursorFromDocuments(values, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var answers [questionCount][]Answer
+	for cur.Next(context.Background()) {
+		candidate := Candidate{}
+		if err := cur.Decode(&candidate); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < questionCount; i++ {
+			answer := Answer{
+				Id:     candidate.Id,
+				Name:   candidate.Name,
+				Votes:  candidate.Votes,
+				Answer: candidate.Answers[i],
+			}
+			answers[i] = append(answers[i], answer)
+		}
+	}
+	return answers
+}
+
+// answerKey identifies an answer regardless of where it was shuffled to.
+func answerKey(id string, name string, votes any, answer string) string {
+	return fmt.Sprintf("%s|%s|%v|%s", id, name, votes, answer)
+}
+
+func TestGetAnswers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("same answers as decoding one at a time", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		documents := testCandidates(5)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, documents...))
+
+		w := httptest.NewRecorder()
+		rs.GetAnswers(w, newTestRequest(http.MethodGet, "/api/senate/answers"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+
+		got := decodeResponse(t, w)["data"].(map[string]any)["answers"].([]any)
+		want := oldAnswers(t, documents)
+		for i := 0; i < questionCount; i++ {
+			wantKeys := map[string]int{}
+			for _, answer := range want[i] {
+				wantKeys[answerKey(answer.Id.Hex(), answer.Name, float64(*answer.Votes), answer.Answer)]++
+			}
+			for _, answer := range got[i].([]any) {
+				answer := answer.(map[string]any)
+				key := answerKey(answer["_id"].(string), answer["name"].(string), answer["votes"], answer["answer"].(string))
+				wantKeys[key]--
+			}
+			for key, count := range wantKeys {
+				if count != 0 {
+					t.Errorf("question %d: %q is off by %d", i, key, count)
+				}
+			}
+		}
+
+		projection := mt.GetStartedEvent().Command.Lookup("projection").Document()
+		for _, field := range []string{"_id", "name", "answers", "votes"} {
+			if _, err := projection.LookupErr(field); err != nil {
+				t.Errorf("projection is missing %q", field)
+			}
+		}
+	})
+
+	mt.Run("empty branch", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch))
+
+		w := httptest.NewRecorder()
+		rs.GetAnswers(w, newTestRequest(http.MethodGet, "/api/senate/answers"))
+
+		got := decodeResponse(t, w)["data"].(map[string]any)["answers"].([]any)
+		for i, question := range got {
+			if question != nil {
+				t.Errorf("question %d: got %v, want null like before", i, question)
+			}
+		}
+	})
+
+	mt.Run("candidate with too few answers", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		short := bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "name", Value: "Jane Doe"},
+			{Key: "answers", Value: bson.A{"a"}},
+			{Key: "votes", Value: int32(0)},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, short))
+
+		w := httptest.NewRecorder()
+		rs.GetAnswers(w, newTestRequest(http.MethodGet, "/api/senate/answers"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		got := decodeResponse(t, w)["data"].(map[string]any)["answers"].([]any)
+		if len(got[0].([]any)) != 1 || len(got[1].([]any)) != 0 {
+			t.Errorf("got %v, want only the first question answered", got)
+		}
+	})
+}
+
+// BenchmarkDecodeAnswers compares decoding the answers one document at a time with decoding them in one batch.
+// It runs on an in-memory cursor, so it measures decoding only, not what the projection saves on the wire.
+func BenchmarkDecodeAnswers(b *testing.B) {
+	documents := testCandidates(10000)
+	values := make([]any, len(documents))
+	for i, document := range documents {
+		values[i] = document
+	}
+
+	b.Run("one at a time", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cur, _ := mongo.NewCursorFromDocuments(values, nil, nil)
+			var candidates []Candidate
+			for cur.Next(context.Background()) {
+				candidate := Candidate{}
+				if err := cur.Decode(&candidate); err != nil {
+					b.Fatal(err)
+				}
+				candidates = append(candidates, candidate)
+			}
+			groupAnswers(candidates)
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cur, _ := mongo.NewCursorFromDocuments(values, nil, nil)
+			var candidates []Candidate
+			if err := cur.All(context.Background(), &candidates); err != nil {
+				b.Fatal(err)
+			}
+			groupAnswers(candidates)
+		}
+	})
+}
+
+func TestPostCandidates(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "malformed body", body: `{"name": "John Smith", "answers": [`},
+		{name: "wrong answer count", body: `{"name": "John Smith", "answers": ["a"]}`},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			rs := &AppResource{Client: mt.Client}
+
+			r := newTestRequest(http.MethodPost, "/api/senate/candidates")
+			r.Body = httpBody(test.body)
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			rs.PostCandidates(w, r)
+
+			if status := decodeResponse(t, w)["status"]; status != string(Fail) {
+				t.Errorf("got JSend status %v, want %q", status, Fail)
+			}
+			if started := mt.GetAllStartedEvents(); len(started) != 0 {
+				t.Errorf("got %d commands, want nothing inserted", len(started))
+			}
+		})
+	}
+}
+
+func TestGetUnvotedCandidates(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("no unvoted candidates", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch))
+
+		w := httptest.NewRecorder()
+		rs.GetUnvotedCandidates(w, newTestRequest(http.MethodGet, "/api/senate/candidates/unvoted"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		candidates, ok := decodeResponse(t, w)["data"].(map[string]any)["candidates"].([]any)
+		if !ok || len(candidates) != 0 {
+			t.Errorf("got candidates %v, want an empty list", candidates)
+		}
+	})
+
+	mt.Run("hidden before voting", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{HideResultsUntilVoted: true}}
+		mt.AddMockResponses(countResponse(0))
+
+		w := httptest.NewRecorder()
+		rs.GetUnvotedCandidates(w, newTestRequest(http.MethodGet, "/api/senate/candidates/unvoted"))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusForbidden)
+		}
+		if status := decodeResponse(t, w)["status"]; status != string(Fail) {
+			t.Errorf("got JSend status %v, want %q", status, Fail)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// contentHash hashes a candidate's name and answers, so two copies can be compared at a glance.
+// Missing answers hash the same as no answers.
+func contentHash(name string, answers []string) string {
+	if answers == nil {
+		answers = []string{}
+	}
+	// Marshalling a struct keeps the field order fixed, so the same content always gives the same hash.
+	content, _ := json.Marshal(CandidateRequest{Name: name, Answers: answers})
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// matchField compares an expected value with the stored one.
+// A nil pointer means the value is missing on that side.
+func matchField(expected *string, stored *string) FieldMatch {
+	match := expected != nil && stored != nil && *expected == *stored
+	return FieldMatch{Match: match, Expected: expected, Stored: stored}
+}
+
+// verifyCandidate compares what the submitter expects with the stored candidate, field by field.
+func verifyCandidate(expected *CandidateRequest, stored *Candidate) CandidateVerification {
+	verification := CandidateVerification{
+		Name:         matchField(&expected.Name, &stored.Name),
+		Hash:         contentHash(stored.Name, stored.Answers),
+		ExpectedHash: contentHash(expected.Name, expected.Answers),
+	}
+	verification.Match = verification.Name.Match
+
+	count := len(expected.Answers)
+	if len(stored.Answers) > count {
+		count = len(stored.Answers)
+	}
+	verification.Answers = make([]FieldMatch, count)
+	for i := 0; i < count; i++ {
+		var expectedAnswer, storedAnswer *string
+		if i < len(expected.Answers) {
+			expectedAnswer = &expected.Answers[i]
+		}
+		if i < len(stored.Answers) {
+			storedAnswer = &stored.Answers[i]
+		}
+
+		verification.Answers[i] = matchField(expectedAnswer, storedAnswer)
+		verification.Match = verification.Match && verification.Answers[i].Match
+	}
+
+	return verification
+}
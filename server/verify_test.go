@@ -0,0 +1,155 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestVerifyCandidate(t *testing.T) {
+	answers := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		name         string
+		expected     CandidateRequest
+		stored       Candidate
+		wantMatch    bool
+		wantName     bool
+		wantAnswers  []bool
+		wantSameHash bool
+	}{
+		{
+			name:         "identical",
+			expected:     CandidateRequest{Name: "John Smith", Answers: answers},
+			stored:       Candidate{Name: "John Smith", Answers: answers},
+			wantMatch:    true,
+			wantName:     true,
+			wantAnswers:  []bool{true, true, true, true},
+			wantSameHash: true,
+		},
+		{
+			name:         "different name",
+			expected:     CandidateRequest{Name: "John Smith", Answers: answers},
+			stored:       Candidate{Name: "Jon Smith", Answers: answers},
+			wantAnswers:  []bool{true, true, true, true},
+			wantSameHash: false,
+		},
+		{
+			name:        "different answer",
+			expected:    CandidateRequest{Name: "John Smith", Answers: answers},
+			stored:      Candidate{Name: "John Smith", Answers: []string{"a", "b", "x", "d"}},
+			wantName:    true,
+			wantAnswers: []bool{true, true, false, true},
+		},
+		{
+			name:        "stored answers truncated",
+			expected:    CandidateRequest{Name: "John Smith", Answers: answers},
+			stored:      Candidate{Name: "John Smith", Answers: answers[:2]},
+			wantName:    true,
+			wantAnswers: []bool{true, true, false, false},
+		},
+		{
+			name:        "stored answers longer",
+			expected:    CandidateRequest{Name: "John Smith", Answers: answers[:2]},
+			stored:      Candidate{Name: "John Smith", Answers: answers},
+			wantName:    true,
+			wantAnswers: []bool{true, true, false, false},
+		},
+		{
+			name:         "nil and empty answers",
+			expected:     CandidateRequest{Name: "John Smith", Answers: []string{}},
+			stored:       Candidate{Name: "John Smith"},
+			wantMatch:    true,
+			wantName:     true,
+			wantAnswers:  []bool{},
+			wantSameHash: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := verifyCandidate(&test.expected, &test.stored)
+
+			if got.Match != test.wantMatch {
+				t.Errorf("got match %v, want %v", got.Match, test.wantMatch)
+			}
+			if got.Name.Match != test.wantName {
+				t.Errorf("got name match %v, want %v", got.Name.Match, test.wantName)
+			}
+			if len(got.Answers) != len(test.wantAnswers) {
+				t.Fatalf("got %d answers compared, want %d", len(got.Answers), len(test.wantAnswers))
+			}
+			for i, answer := range got.Answers {
+				if answer.Match != test.wantAnswers[i] {
+					t.Errorf("answer %d: got match %v, want %v", i, answer.Match, test.wantAnswers[i])
+				}
+				if (i < len(test.expected.Answers)) != (answer.Expected != nil) {
+					t.Errorf("answer %d: expected side should only be missing past the expected answers", i)
+				}
+				if (i < len(test.stored.Answers)) != (answer.Stored != nil) {
+					t.Errorf("answer %d: stored side should only be missing past the stored answers", i)
+				}
+			}
+			if (got.Hash == got.ExpectedHash) != test.wantSameHash {
+				t.Errorf("got hashes %s and %s, want same %v", got.Hash, got.ExpectedHash, test.wantSameHash)
+			}
+		})
+	}
+}
+
+func TestPostVerifyCandidate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	newVerifyRequest := func(id string) *http.Request {
+		r := withURLParam(newTestRequest(http.MethodPost, "/api/senate/candidates/"+id+"/verify"), "id", id)
+		r.Body = httpBody(`{"name": "John Smith", "answers": ["a", "b", "c", "d"]}`)
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+
+	mt.Run("matches the stored candidate", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, testCandidate))
+
+		w := httptest.NewRecorder()
+		rs.PostVerifyCandidate(w, newVerifyRequest(testCandidate[0].Value.(primitive.ObjectID).Hex()))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		verification := decodeResponse(t, w)["data"].(map[string]any)["verification"].(map[string]any)
+		if verification["match"] != true {
+			t.Errorf("got %v, want a match", verification)
+		}
+	})
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{name: "missing candidate", id: primitive.NewObjectID().Hex()},
+		{name: "malformed id", id: "not-an-id"},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			rs := &AppResource{Client: mt.Client}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch))
+
+			w := httptest.NewRecorder()
+			rs.PostVerifyCandidate(w, newVerifyRequest(test.id))
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusNotFound)
+			}
+			response := decodeResponse(t, w)
+			if response["status"] != string(Fail) {
+				t.Errorf("got JSend status %v, want %q", response["status"], Fail)
+			}
+			if message := response["data"].(map[string]any)["_id"]; message == nil {
+				t.Error("the failure should explain what's wrong with the id")
+			}
+		})
+	}
+}
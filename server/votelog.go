@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+var ErrVoteAlreadyLogged = errors.New("vote already logged")
+var ErrVotesNotCovered = errors.New("some votes are not in the vote log")
+
+// errCandidateNotFound aborts a vote transaction when there's no candidate to count the vote for.
+var errCandidateNotFound = errors.New("candidate not found")
+
+// voteTransaction is how vote log transactions run: on a snapshot,
+// so that a recount conflicts with any vote that's counted while it runs instead of overwriting it.
+var voteTransaction = options.Transaction().
+	SetReadConcern(readconcern.Snapshot()).
+	SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+
+// voteLogCollection returns the collection holding every vote cast in a branch.
+// Entries are only ever added, so the vote counts can always be rebuilt from it.
+func (rs *AppResource) voteLogCollection(branch string) *mongo.Collection {
+	return rs.Db().Collection(branch + "_votelog")
+}
+
+// logVote adds a vote for the candidate to the log and increments the candidate's counter in one transaction,
+// so the log and the counter never disagree. It reports whether the candidate exists.
+// If the voter has already logged a vote for the candidate with the same idempotency key,
+// the vote isn't counted again and ErrVoteAlreadyLogged is returned.
+func (rs *AppResource) logVote(r *http.Request, branch string, candidateId primitive.ObjectID, idempotencyKey string) (bool, error) {
+	entry := bson.M{
+		"_id":         primitive.NewObjectID(),
+		"branch":      branch,
+		"candidate":   candidateId,
+		"timestamp":   time.Now(),
+		"fingerprint": rs.voterFingerprint(r),
+	}
+	if idempotencyKey != "" {
+		entry["idempotencyKey"] = idempotencyKey
+	}
+
+	session, err := rs.Client.StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(r.Context())
+
+	_, err = session.WithTransaction(r.Context(), func(ctx mongo.SessionContext) (any, error) {
+		if _, err := rs.voteLogCollection(branch).InsertOne(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		result, err := rs.Db().Collection(branch).UpdateOne(
+			ctx,
+			bson.M{"_id": candidateId},
+			bson.M{"$inc": bson.M{"votes": 1}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, errCandidateNotFound
+		}
+		return nil, nil
+	}, voteTransaction)
+	if errors.Is(err, errCandidateNotFound) {
+		return false, nil
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return true, ErrVoteAlreadyLogged
+	}
+	return err == nil, err
+}
+
+// loggedVoteCounts counts the logged votes for each candidate in the branch.
+func (rs *AppResource) loggedVoteCounts(ctx context.Context, branch string) (map[primitive.ObjectID]int32, error) {
+	cur, err := rs.voteLogCollection(branch).Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$candidate", "votes": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var groups []struct {
+		Id    primitive.ObjectID `bson:"_id"`
+		Votes int32              `bson:"votes"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[primitive.ObjectID]int32, len(groups))
+	for _, group := range groups {
+		counts[group.Id] = group.Votes
+	}
+	return counts, nil
+}
+
+// recountEntries fills in each entry's count from the log.
+// It returns the entries whose counters have more votes than the log,
+// which happens when votes were cast before the vote log was turned on.
+func recountEntries(entries []RecountEntry, counts map[primitive.ObjectID]int32) []RecountEntry {
+	uncovered := []RecountEntry{}
+	for i := range entries {
+		entries[i].After = counts[entries[i].Id]
+		if entries[i].Before > entries[i].After {
+			uncovered = append(uncovered, entries[i])
+		}
+	}
+	return uncovered
+}
+
+// recount rebuilds every candidate's votes from the log in one transaction, so votes counted meanwhile aren't lost.
+// It also returns the candidates whose counters have more votes than the log.
+// Unless force is set, nothing is changed if there are any, and ErrVotesNotCovered is returned.
+func (rs *AppResource) recount(ctx context.Context, branch string, force bool) ([]RecountEntry, []RecountEntry, error) {
+	session, err := rs.Client.StartSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var uncovered []RecountEntry
+	entries, err := session.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+		counts, err := rs.loggedVoteCounts(ctx, branch)
+		if err != nil {
+			return nil, err
+		}
+
+		collection := rs.Db().Collection(branch)
+		opts := options.Find().SetProjection(bson.M{"name": 1, "votes": 1})
+		cur, err := collection.Find(ctx, bson.D{}, opts)
+		if err != nil {
+			return nil, err
+		}
+		defer cur.Close(ctx)
+
+		entries := []RecountEntry{}
+		if err := cur.All(ctx, &entries); err != nil {
+			return nil, err
+		}
+
+		uncovered = recountEntries(entries, counts)
+		if len(uncovered) > 0 && !force {
+			return nil, ErrVotesNotCovered
+		}
+
+		for _, entry := range entries {
+			if entry.After == entry.Before {
+				continue
+			}
+			_, err := collection.UpdateOne(
+				ctx,
+				bson.M{"_id": entry.Id},
+				bson.M{"$set": bson.M{"votes": entry.After}},
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	}, voteTransaction)
+	if errors.Is(err, ErrVotesNotCovered) {
+		return nil, uncovered, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries.([]RecountEntry), uncovered, nil
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// commandNames lists the commands that were sent to the mock database.
+func commandNames(mt *mtest.T) []string {
+	var names []string
+	for _, started := range mt.GetAllStartedEvents() {
+		names = append(names, started.CommandName)
+	}
+	return names
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLogVote(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("logs and counts in one transaction", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		matched, err := rs.logVote(newTestRequest(http.MethodPatch, "/"), "senate", primitive.NewObjectID(), "key")
+		if !matched || err != nil {
+			t.Fatalf("got %v, %v, want the vote counted", matched, err)
+		}
+
+		started := mt.GetAllStartedEvents()
+		if names := commandNames(mt); !equalStrings(names, []string{"insert", "update", "commitTransaction"}) {
+			t.Fatalf("got commands %v", names)
+		}
+		if started[0].Command.Lookup("startTransaction").Boolean() != true {
+			t.Error("the log entry should start the transaction")
+		}
+		if started[0].Command.Lookup("txnNumber").Int64() != started[1].Command.Lookup("txnNumber").Int64() {
+			t.Error("the log entry and the increment should be in the same transaction")
+		}
+		entry := started[0].Command.Lookup("documents").Array().Index(0).Value().Document()
+		if entry.Lookup("idempotencyKey").StringValue() != "key" {
+			t.Errorf("got entry %v, want the idempotency key stored", entry)
+		}
+	})
+
+	mt.Run("retry with the same key isn't counted again", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		_, err := rs.logVote(newTestRequest(http.MethodPatch, "/"), "senate", primitive.NewObjectID(), "key")
+		if !errors.Is(err, ErrVoteAlreadyLogged) {
+			t.Fatalf("got error %v, want %v", err, ErrVoteAlreadyLogged)
+		}
+		if names := commandNames(mt); !equalStrings(names, []string{"insert", "abortTransaction"}) {
+			t.Errorf("got commands %v, want no increment", names)
+		}
+	})
+
+	mt.Run("missing candidate isn't logged", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		matched, err := rs.logVote(newTestRequest(http.MethodPatch, "/"), "senate", primitive.NewObjectID(), "")
+		if matched || err != nil {
+			t.Fatalf("got %v, %v, want nothing counted", matched, err)
+		}
+		if names := commandNames(mt); !equalStrings(names, []string{"insert", "update", "abortTransaction"}) {
+			t.Errorf("got commands %v, want the log entry rolled back", names)
+		}
+	})
+}
+
+func TestRecountEntries(t *testing.T) {
+	a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	entries := []RecountEntry{
+		{Id: a, Before: 3},
+		{Id: b, Before: 1},
+		{Id: c, Before: 2},
+	}
+	counts := map[primitive.ObjectID]int32{a: 3, b: 2}
+
+	uncovered := recountEntries(entries, counts)
+
+	wantAfter := []int32{3, 2, 0}
+	for i, entry := range entries {
+		if entry.After != wantAfter[i] {
+			t.Errorf("entry %d: got %d after, want %d", i, entry.After, wantAfter[i])
+		}
+	}
+	if len(uncovered) != 1 || uncovered[0].Id != c {
+		t.Errorf("got uncovered %v, want only the candidate with votes missing from the log", uncovered)
+	}
+}
+
+func TestPostRecount(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	config := Config{AdminToken: "secret", VoteLog: true}
+	id := primitive.NewObjectID()
+	candidate := func(votes int32) bson.D {
+		return bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "John Smith"}, {Key: "votes", Value: votes}}
+	}
+	logged := mtest.CreateCursorResponse(0, "voting.senate_votelog", mtest.FirstBatch,
+		bson.D{{Key: "_id", Value: id}, {Key: "votes", Value: int32(2)}},
+	)
+
+	newRecountRequest := func() *http.Request {
+		r := newTestRequest(http.MethodPost, "/api/senate/recount")
+		r.Header.Set("X-Admin-Token", "secret")
+		return r
+	}
+
+	mt.Run("refuses when the log doesn't cover the counter", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		mt.AddMockResponses(
+			logged,
+			mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, candidate(5)),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := httptest.NewRecorder()
+		rs.PostRecount(w, newRecountRequest())
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusConflict)
+		}
+		if names := commandNames(mt); !equalStrings(names, []string{"aggregate", "find", "abortTransaction"}) {
+			t.Errorf("got commands %v, want no votes changed", names)
+		}
+		if uncovered := decodeResponse(t, w)["data"].(map[string]any)["uncovered"].([]any); len(uncovered) != 1 {
+			t.Errorf("got uncovered %v, want the inflated candidate reported", uncovered)
+		}
+	})
+
+	mt.Run("force applies the log even when it doesn't cover the counter", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		mt.AddMockResponses(
+			logged,
+			mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, candidate(5)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		r := newRecountRequest()
+		r.URL.RawQuery = "force=true"
+		w := httptest.NewRecorder()
+		rs.PostRecount(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		if names := commandNames(mt); !equalStrings(names, []string{"aggregate", "find", "update", "commitTransaction"}) {
+			t.Errorf("got commands %v, want the inflated counter rebuilt", names)
+		}
+		data := decodeResponse(t, w)["data"].(map[string]any)
+		entry := data["recount"].([]any)[0].(map[string]any)
+		if entry["before"] != float64(5) || entry["after"] != float64(2) {
+			t.Errorf("got %v, want 5 before and 2 after", entry)
+		}
+		if uncovered := data["uncovered"].([]any); len(uncovered) != 1 {
+			t.Errorf("got uncovered %v, want the inflated candidate reported", uncovered)
+		}
+	})
+
+	mt.Run("restores votes missing from the counter", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		mt.AddMockResponses(
+			logged,
+			mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, candidate(1)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := httptest.NewRecorder()
+		rs.PostRecount(w, newRecountRequest())
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		if names := commandNames(mt); !equalStrings(names, []string{"aggregate", "find", "update", "commitTransaction"}) {
+			t.Errorf("got commands %v, want the recount in one transaction", names)
+		}
+		recount := decodeResponse(t, w)["data"].(map[string]any)["recount"].([]any)
+		entry := recount[0].(map[string]any)
+		if entry["before"] != float64(1) || entry["after"] != float64(2) {
+			t.Errorf("got %v, want 1 before and 2 after", entry)
+		}
+	})
+
+	mt.Run("only admins can recount", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+
+		w := httptest.NewRecorder()
+		rs.PostRecount(w, newTestRequest(http.MethodPost, "/api/senate/recount"))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusForbidden)
+		}
+	})
+}
+
+func TestPatchVotesWithVoteLog(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("retry of a counted vote still records the voter", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{VoteLog: true}}
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		id := primitive.NewObjectID()
+		r := withURLParam(newTestRequest(http.MethodPatch, "/api/senate/candidates/"+id.Hex()+"/votes"), "id", id.Hex())
+		r.Header.Set("Idempotency-Key", "key")
+		w := httptest.NewRecorder()
+		rs.PatchVotes(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		if status := decodeResponse(t, w)["status"]; status != string(Success) {
+			t.Errorf("got JSend status %v, want %q", status, Success)
+		}
+		started := mt.GetAllStartedEvents()
+		if names := commandNames(mt); !equalStrings(names, []string{"insert", "abortTransaction", "update"}) {
+			t.Fatalf("got commands %v, want the voter recorded after the duplicate", names)
+		}
+		if collection := started[2].Command.Lookup("update").StringValue(); collection != "senate_voters" {
+			t.Errorf("voter was recorded in %q, want %q", collection, "senate_voters")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// votersCollection returns the collection recording who has voted in a branch.
+func (rs *AppResource) votersCollection(branch string) *mongo.Collection {
+	return rs.Db().Collection(branch + "_voters")
+}
+
+// voterFingerprint identifies a voter by hashing their IP address and user agent.
+func (rs *AppResource) voterFingerprint(r *http.Request) string {
+	hash := sha256.Sum256([]byte(rs.clientIP(r) + "\x00" + r.UserAgent()))
+	return hex.EncodeToString(hash[:])
+}
+
+// isAdmin checks if the request carries the configured admin token.
+// When RequireHTTPS is set, the token is ignored on insecure requests.
+func (rs *AppResource) isAdmin(r *http.Request) bool {
+	token := r.Header.Get("X-Admin-Token")
+	if rs.Config.AdminToken == "" || token == "" {
+		return false
+	}
+	if rs.Config.RequireHTTPS && !rs.isSecure(r) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(rs.Config.AdminToken)) == 1
+}
+
+// recordVoter remembers that the requesting voter has voted in the branch.
+func (rs *AppResource) recordVoter(r *http.Request, branch string) error {
+	_, err := rs.votersCollection(branch).UpdateOne(
+		r.Context(),
+		bson.M{"_id": rs.voterFingerprint(r)},
+		bson.M{"$setOnInsert": bson.M{"votedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// hasVoted checks if the requesting voter has voted in the branch.
+func (rs *AppResource) hasVoted(r *http.Request, branch string) (bool, error) {
+	count, err := rs.votersCollection(branch).CountDocuments(
+		r.Context(),
+		bson.M{"_id": rs.voterFingerprint(r)},
+		options.Count().SetLimit(1),
+	)
+	return count > 0, err
+}
+
+// canSeeResults checks if the requesting voter is allowed to see vote counts.
+// Admins can always see them; everyone else has to vote first when HideResultsUntilVoted is set.
+func (rs *AppResource) canSeeResults(r *http.Request, branch string) (bool, error) {
+	if !rs.Config.HideResultsUntilVoted || rs.isAdmin(r) {
+		return true, nil
+	}
+	return rs.hasVoted(r, branch)
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newTestRequest makes a request for the senate branch, as if it had gone through BranchCtx.
+func newTestRequest(method string, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	return r.WithContext(context.WithValue(r.Context(), "branch", "senate"))
+}
+
+// httpBody wraps a string so it can be used as a request body.
+func httpBody(body string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(body))
+}
+
+// withURLParam sets a chi URL parameter on the request, as if it had been routed.
+func withURLParam(r *http.Request, key string, value string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add(key, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+// decodeResponse decodes the JSend response that the handler wrote.
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	response := map[string]any{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("could not decode response %q: %v", w.Body.String(), err)
+	}
+	return response
+}
+
+// countResponse is the mocked reply to a CountDocuments call.
+func countResponse(count int32) bson.D {
+	if count == 0 {
+		return mtest.CreateCursorResponse(0, "voting.senate_voters", mtest.FirstBatch)
+	}
+	return mtest.CreateCursorResponse(0, "voting.senate_voters", mtest.FirstBatch, bson.D{{Key: "n", Value: count}})
+}
+
+var testCandidate = bson.D{
+	{Key: "_id", Value: primitive.NewObjectID()},
+	{Key: "name", Value: "John Smith"},
+	{Key: "votes", Value: int32(4)},
+	{Key: "answers", Value: bson.A{"a", "b", "c", "d"}},
+}
+
+func TestGetLeaderboardHideResultsUntilVoted(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	config := Config{HideResultsUntilVoted: true, AdminToken: "secret"}
+
+	mt.Run("hidden before voting", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		mt.AddMockResponses(countResponse(0))
+
+		w := httptest.NewRecorder()
+		rs.GetLeaderboard(w, newTestRequest(http.MethodGet, "/api/senate/leaderboard"))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusForbidden)
+		}
+		if status := decodeResponse(t, w)["status"]; status != string(Fail) {
+			t.Errorf("got JSend status %v, want %q", status, Fail)
+		}
+	})
+
+	mt.Run("visible after voting", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		mt.AddMockResponses(
+			countResponse(1),
+			mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, testCandidate),
+		)
+
+		w := httptest.NewRecorder()
+		rs.GetLeaderboard(w, newTestRequest(http.MethodGet, "/api/senate/leaderboard"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		leaderboard := decodeResponse(t, w)["data"].(map[string]any)["leaderboard"].([]any)
+		if votes := leaderboard[0].(map[string]any)["votes"]; votes != float64(4) {
+			t.Errorf("got votes %v, want 4", votes)
+		}
+	})
+
+	mt.Run("admins can always see results", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: config}
+		// There is no count response, so checking whether the admin voted would fail the request.
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, testCandidate))
+
+		r := newTestRequest(http.MethodGet, "/api/senate/leaderboard")
+		r.Header.Set("X-Admin-Token", "secret")
+		w := httptest.NewRecorder()
+		rs.GetLeaderboard(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestGetCandidatesHideResultsUntilVoted(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	tests := []struct {
+		name      string
+		voted     int32
+		wantVotes bool
+	}{
+		{name: "votes left out before voting", voted: 0, wantVotes: false},
+		{name: "votes included after voting", voted: 1, wantVotes: true},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			rs := &AppResource{Client: mt.Client, Config: Config{HideResultsUntilVoted: true}}
+			mt.AddMockResponses(
+				countResponse(test.voted),
+				mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, testCandidate),
+			)
+
+			w := httptest.NewRecorder()
+			rs.GetCandidates(w, newTestRequest(http.MethodGet, "/api/senate/candidates"))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+			}
+			candidates := decodeResponse(t, w)["data"].(map[string]any)["candidates"].([]any)
+			_, hasVotes := candidates[0].(map[string]any)["votes"]
+			if hasVotes != test.wantVotes {
+				t.Errorf("got votes present %v, want %v", hasVotes, test.wantVotes)
+			}
+		})
+	}
+}
+
+func TestGetAnswersHideResultsUntilVoted(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("votes left out before voting", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{HideResultsUntilVoted: true}}
+		mt.AddMockResponses(
+			countResponse(0),
+			mtest.CreateCursorResponse(0, "voting.senate", mtest.FirstBatch, testCandidate),
+		)
+
+		w := httptest.NewRecorder()
+		rs.GetAnswers(w, newTestRequest(http.MethodGet, "/api/senate/answers"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		answers := decodeResponse(t, w)["data"].(map[string]any)["answers"].([]any)
+		for i, question := range answers {
+			if _, hasVotes := question.([]any)[0].(map[string]any)["votes"]; hasVotes {
+				t.Errorf("question %d: votes should be left out", i)
+			}
+		}
+	})
+}
+
+func TestPatchVotesRecordsVoter(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("voter is recorded once the vote counts", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		id := testCandidate[0].Value.(primitive.ObjectID)
+		r := withURLParam(newTestRequest(http.MethodPatch, "/api/senate/candidates/"+id.Hex()+"/votes"), "id", id.Hex())
+		w := httptest.NewRecorder()
+		rs.PatchVotes(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		started := mt.GetAllStartedEvents()
+		if len(started) != 2 {
+			t.Fatalf("got %d commands, want the increment and the voter upsert", len(started))
+		}
+		if collection := started[1].Command.Lookup("update").StringValue(); collection != "senate_voters" {
+			t.Errorf("voter was recorded in %q, want %q", collection, "senate_voters")
+		}
+	})
+}
+
+func TestGetTurnoutPercentage(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("percentage clamped to the eligible count", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client, Config: Config{EligibleVoters: map[string]int{"senate": 4}}}
+		mt.AddMockResponses(countResponse(5))
+
+		w := httptest.NewRecorder()
+		rs.GetTurnoutPercentage(w, newTestRequest(http.MethodGet, "/api/senate/turnout"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		turnout := decodeResponse(t, w)["data"].(map[string]any)["turnout"].(map[string]any)
+		if turnout["voters"] != float64(5) || turnout["eligibleVoters"] != float64(4) {
+			t.Errorf("got %v, want 5 voters out of 4 eligible", turnout)
+		}
+		if turnout["percentage"] != float64(100) || turnout["percentageAvailable"] != true {
+			t.Errorf("got %v, want a percentage of 100", turnout)
+		}
+	})
+
+	mt.Run("no percentage without an eligible count", func(mt *mtest.T) {
+		rs := &AppResource{Client: mt.Client}
+		mt.AddMockResponses(countResponse(5))
+
+		w := httptest.NewRecorder()
+		rs.GetTurnoutPercentage(w, newTestRequest(http.MethodGet, "/api/senate/turnout"))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		turnout := decodeResponse(t, w)["data"].(map[string]any)["turnout"].(map[string]any)
+		if percentage, ok := turnout["percentage"]; !ok || percentage != nil {
+			t.Errorf("got percentage %v, want null", percentage)
+		}
+		if turnout["percentageAvailable"] != false {
+			t.Errorf("got percentageAvailable %v, want false", turnout["percentageAvailable"])
+		}
+	})
+}